@@ -0,0 +1,49 @@
+package playlist
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// writeCUE renders tracks as a CUE sheet, one FILE/TRACK pair per track
+// (each track here is its own physical file rather than an index into one
+// continuous album file), grouped under a REM comment whenever the album
+// changes.
+func writeCUE(w io.Writer, tracks []Track) error {
+	lastAlbum := ""
+	for i, t := range tracks {
+		if t.Album != lastAlbum {
+			if _, err := fmt.Fprintf(w, "REM ALBUM %q\n", t.Album); err != nil {
+				return err
+			}
+			lastAlbum = t.Album
+		}
+
+		ext := filepath.Ext(t.Filename)
+		fileType := "MP3"
+		switch ext {
+		case ".flac":
+			fileType = "FLAC"
+		case ".m4a", ".mp4":
+			fileType = "MP4"
+		}
+
+		if _, err := fmt.Fprintf(w, "FILE %q %s\n", filepath.Base(t.Filename), fileType); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "  TRACK %02d AUDIO\n", i+1); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "    TITLE %q\n", t.Title); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "    PERFORMER %q\n", t.Artist); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, "    INDEX 01 00:00:00"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package playlist
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// jsonTrack is the wire shape for the JSON manifest: a flat struct with a
+// plain integer Duration (seconds) rather than a time.Duration, so the
+// output is usable by tools outside Go.
+type jsonTrack struct {
+	Artist   string `json:"artist"`
+	Album    string `json:"album"`
+	Title    string `json:"title"`
+	Filename string `json:"filename"`
+	Duration int    `json:"durationSeconds"`
+}
+
+// writeJSON renders tracks as a JSON array manifest.
+func writeJSON(w io.Writer, tracks []Track) error {
+	manifest := make([]jsonTrack, len(tracks))
+	for i, t := range tracks {
+		manifest[i] = jsonTrack{
+			Artist:   t.Artist,
+			Album:    t.Album,
+			Title:    t.Title,
+			Filename: t.Filename,
+			Duration: seconds(t.Duration),
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(manifest)
+}
@@ -0,0 +1,24 @@
+package playlist
+
+import (
+	"fmt"
+	"io"
+)
+
+// writeM3U renders tracks as an extended M3U playlist. The same output
+// serves both .m3u and .m3u8 (the latter simply asserts UTF-8, which Go
+// strings already are).
+func writeM3U(w io.Writer, tracks []Track) error {
+	if _, err := fmt.Fprintln(w, "#EXTM3U"); err != nil {
+		return err
+	}
+	for _, t := range tracks {
+		if _, err := fmt.Fprintf(w, "#EXTINF:%d,%s - %s\n", seconds(t.Duration), t.Artist, t.Title); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, t.Filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
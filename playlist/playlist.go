@@ -0,0 +1,51 @@
+// Package playlist renders an ordered list of tracks into common playlist
+// file formats (M3U/M3U8, PLS, JSON, CUE).
+package playlist
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Track is the subset of song metadata a playlist needs to render an entry.
+type Track struct {
+	Artist   string
+	Album    string
+	Title    string
+	Filename string
+	Duration time.Duration
+}
+
+// Format selects which playlist file format Write renders.
+type Format string
+
+const (
+	FormatM3U  Format = "m3u"
+	FormatM3U8 Format = "m3u8"
+	FormatPLS  Format = "pls"
+	FormatJSON Format = "json"
+	FormatCUE  Format = "cue"
+)
+
+// Write renders tracks, in order, to w using format.
+func Write(w io.Writer, tracks []Track, format Format) error {
+	switch format {
+	case FormatM3U, FormatM3U8:
+		return writeM3U(w, tracks)
+	case FormatPLS:
+		return writePLS(w, tracks)
+	case FormatJSON:
+		return writeJSON(w, tracks)
+	case FormatCUE:
+		return writeCUE(w, tracks)
+	default:
+		return fmt.Errorf("playlist: unknown format %q", format)
+	}
+}
+
+// seconds rounds a duration down to whole seconds, as most playlist formats
+// expect integer lengths.
+func seconds(d time.Duration) int {
+	return int(d / time.Second)
+}
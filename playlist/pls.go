@@ -0,0 +1,30 @@
+package playlist
+
+import (
+	"fmt"
+	"io"
+)
+
+// writePLS renders tracks in the PLS format used by Winamp/XMMS-era players.
+func writePLS(w io.Writer, tracks []Track) error {
+	if _, err := fmt.Fprintln(w, "[playlist]"); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "NumberOfEntries=%d\n", len(tracks)); err != nil {
+		return err
+	}
+	for i, t := range tracks {
+		n := i + 1
+		if _, err := fmt.Fprintf(w, "File%d=%s\n", n, t.Filename); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "Title%d=%s - %s\n", n, t.Artist, t.Title); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "Length%d=%d\n", n, seconds(t.Duration)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "Version=2")
+	return err
+}
@@ -0,0 +1,286 @@
+// Package scanner walks a music library and keeps a persistent, on-disk
+// index of which folders have changed since the last run, so a caller only
+// has to re-read tags for folders that are new or modified.
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/travissimon/songpicker/tagreader"
+)
+
+var audioExts = map[string]bool{
+	".mp3":  true,
+	".flac": true,
+	".m4a":  true,
+	".mp4":  true,
+}
+
+var imageExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+}
+
+// Track is the subset of song metadata the scanner caches so unchanged
+// folders don't need their tags re-read on the next Scan.
+type Track struct {
+	Title    string
+	Artist   string
+	Album    string
+	Genre    string
+	TrackNum string
+	Filename string
+	Filesize int64
+	Duration time.Duration
+
+	//ReplayGain fields, raw as read from the tag (see tagreader.Tags).
+	TrackGain string
+	TrackPeak string
+	AlbumGain string
+	AlbumPeak string
+}
+
+// fileStamp is the mtime+size signature of a single file, used to detect
+// whether a folder's contents differ from what's in the index.
+type fileStamp struct {
+	Path    string
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// folderEntry is everything the index remembers about one folder.
+type folderEntry struct {
+	ModTime time.Time
+	Hash    string
+	Tracks  []Track
+}
+
+// Index is the on-disk, JSON-serialized record of every folder the scanner
+// has seen, keyed by folder path.
+type Index struct {
+	Folders map[string]folderEntry
+}
+
+// Result reports what changed during a Scan, plus the full, up-to-date set
+// of tracks across the whole library (cached entries plus freshly read
+// ones).
+type Result struct {
+	New     []string
+	Changed []string
+	Deleted []string
+	Tracks  []Track
+}
+
+// Scanner recursively walks SrcDir and maintains a persistent index at
+// IndexPath so repeat scans only re-read tags for folders that changed.
+type Scanner struct {
+	SrcDir    string
+	IndexPath string
+	TagNames  []string
+
+	index Index
+}
+
+// New creates a Scanner over srcDir, backed by a JSON index file at
+// indexPath. tagNames is passed through to tagreader.Read for any folder
+// that needs its tags (re-)read; a nil/empty slice tries every backend.
+func New(srcDir, indexPath string, tagNames []string) *Scanner {
+	return &Scanner{
+		SrcDir:    srcDir,
+		IndexPath: indexPath,
+		TagNames:  tagNames,
+		index:     Index{Folders: make(map[string]folderEntry)},
+	}
+}
+
+// Load reads the index file from disk, if it exists. A missing file is not
+// an error; the scanner simply starts with an empty index.
+func (s *Scanner) Load() error {
+	b, err := os.ReadFile(s.IndexPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var idx Index
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return err
+	}
+	if idx.Folders == nil {
+		idx.Folders = make(map[string]folderEntry)
+	}
+	s.index = idx
+	return nil
+}
+
+// Save writes the current index to disk as JSON.
+func (s *Scanner) Save() error {
+	b, err := json.MarshalIndent(s.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.IndexPath, b, 0644)
+}
+
+// Scan walks SrcDir, grouping files by parent folder, and compares each
+// folder against the index. Folders whose file set (name+size+mtime) hasn't
+// changed have their cached Tracks reused; new or changed folders have
+// their tags re-read via tagreader. The in-memory index is updated in
+// place; call Save to persist it.
+func (s *Scanner) Scan(ctx context.Context) (Result, error) {
+	folders, err := groupByFolder(s.SrcDir)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	seen := make(map[string]bool)
+	updated := make(map[string]folderEntry, len(folders))
+
+	for folderPath, files := range folders {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		seen[folderPath] = true
+
+		hash := hashFiles(files)
+		prev, existed := s.index.Folders[folderPath]
+
+		if existed && prev.Hash == hash {
+			updated[folderPath] = prev
+			result.Tracks = append(result.Tracks, prev.Tracks...)
+			continue
+		}
+
+		tracks, err := readTracks(files, s.TagNames)
+		if err != nil {
+			return Result{}, err
+		}
+
+		entry := folderEntry{ModTime: latestModTime(files), Hash: hash, Tracks: tracks}
+		updated[folderPath] = entry
+		result.Tracks = append(result.Tracks, tracks...)
+
+		if existed {
+			result.Changed = append(result.Changed, folderPath)
+		} else {
+			result.New = append(result.New, folderPath)
+		}
+	}
+
+	for folderPath := range s.index.Folders {
+		if !seen[folderPath] {
+			result.Deleted = append(result.Deleted, folderPath)
+		}
+	}
+
+	s.index.Folders = updated
+	sort.Strings(result.New)
+	sort.Strings(result.Changed)
+	sort.Strings(result.Deleted)
+	return result, nil
+}
+
+// groupByFolder recursively walks root and buckets audio/image files by
+// their parent directory.
+func groupByFolder(root string) (map[string][]fileStamp, error) {
+	folders := make(map[string][]fileStamp)
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if !audioExts[ext] && !imageExts[ext] {
+			return nil
+		}
+		dir := filepath.Dir(p)
+		folders[dir] = append(folders[dir], fileStamp{
+			Path:    p,
+			Name:    filepath.Base(p),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+// hashFiles produces a stable fingerprint of a folder's contents from each
+// file's name, size and mtime, so unchanged folders hash identically across
+// runs regardless of file iteration order.
+func hashFiles(files []fileStamp) string {
+	sorted := make([]fileStamp, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	h := sha256.New()
+	for _, f := range sorted {
+		fmt.Fprintf(h, "%s|%d|%d\n", f.Name, f.Size, f.ModTime.UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func latestModTime(files []fileStamp) time.Time {
+	var latest time.Time
+	for _, f := range files {
+		if f.ModTime.After(latest) {
+			latest = f.ModTime
+		}
+	}
+	return latest
+}
+
+// readTracks reads tags for every audio file in files, skipping image
+// files entirely.
+func readTracks(files []fileStamp, tagNames []string) ([]Track, error) {
+	var tracks []Track
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name))
+		if !audioExts[ext] {
+			continue
+		}
+		tags, err := tagreader.Read(f.Path, tagNames)
+		if err != nil {
+			continue
+		}
+		duration, err := tagreader.Duration(f.Path)
+		if err != nil {
+			log.Printf("could not determine duration of %s: %v", f.Path, err)
+		}
+		tracks = append(tracks, Track{
+			Title:     tags.Title,
+			Artist:    tags.Artist,
+			Album:     tags.Album,
+			Genre:     tags.Genre,
+			TrackNum:  tags.Track,
+			Filename:  f.Path,
+			Filesize:  f.Size,
+			Duration:  duration,
+			TrackGain: tags.TrackGain,
+			TrackPeak: tags.TrackPeak,
+			AlbumGain: tags.AlbumGain,
+			AlbumPeak: tags.AlbumPeak,
+		})
+	}
+	return tracks, nil
+}
@@ -0,0 +1,180 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeStub(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("stub"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGroupByFolderBucketsAudioAndImageOnly(t *testing.T) {
+	root := t.TempDir()
+	writeStub(t, filepath.Join(root, "Artist", "Album", "01.mp3"))
+	writeStub(t, filepath.Join(root, "Artist", "Album", "cover.jpg"))
+	writeStub(t, filepath.Join(root, "Artist", "Album", "notes.txt"))
+	writeStub(t, filepath.Join(root, "Other", "02.flac"))
+
+	folders, err := groupByFolder(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	album := filepath.Join(root, "Artist", "Album")
+	if got, want := len(folders[album]), 2; got != want {
+		t.Fatalf("got %d files in %s, want %d (txt file should be skipped)", got, album, want)
+	}
+	other := filepath.Join(root, "Other")
+	if got, want := len(folders[other]), 1; got != want {
+		t.Fatalf("got %d files in %s, want %d", got, other, want)
+	}
+}
+
+func TestHashFilesStableRegardlessOfOrder(t *testing.T) {
+	now := time.Now()
+	a := fileStamp{Name: "a.mp3", Size: 10, ModTime: now}
+	b := fileStamp{Name: "b.mp3", Size: 20, ModTime: now}
+
+	h1 := hashFiles([]fileStamp{a, b})
+	h2 := hashFiles([]fileStamp{b, a})
+	if h1 != h2 {
+		t.Errorf("hashFiles order-dependent: %q != %q", h1, h2)
+	}
+
+	b.Size = 21
+	h3 := hashFiles([]fileStamp{a, b})
+	if h1 == h3 {
+		t.Error("hashFiles did not change when a file's size changed")
+	}
+}
+
+func TestScanFirstRunPopulatesNew(t *testing.T) {
+	root := t.TempDir()
+	writeStub(t, filepath.Join(root, "Artist", "Album", "01.mp3"))
+	writeStub(t, filepath.Join(root, "Artist", "Album", "cover.jpg"))
+
+	s := New(root, filepath.Join(t.TempDir(), "index.json"), nil)
+	result, err := s.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	album := filepath.Join(root, "Artist", "Album")
+	if len(result.New) != 1 || result.New[0] != album {
+		t.Errorf("New = %v, want [%s]", result.New, album)
+	}
+	if len(result.Changed) != 0 {
+		t.Errorf("Changed = %v, want empty", result.Changed)
+	}
+	if len(result.Deleted) != 0 {
+		t.Errorf("Deleted = %v, want empty", result.Deleted)
+	}
+}
+
+func TestScanRescanWithNoChangesReusesCache(t *testing.T) {
+	root := t.TempDir()
+	writeStub(t, filepath.Join(root, "Artist", "Album", "01.mp3"))
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+
+	first := New(root, indexPath, nil)
+	if _, err := first.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	second := New(root, indexPath, nil)
+	if err := second.Load(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := second.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.New) != 0 {
+		t.Errorf("New = %v, want empty on an unchanged rescan", result.New)
+	}
+	if len(result.Changed) != 0 {
+		t.Errorf("Changed = %v, want empty on an unchanged rescan", result.Changed)
+	}
+}
+
+func TestScanFileRemovedTriggersDeleted(t *testing.T) {
+	root := t.TempDir()
+	albumPath := filepath.Join(root, "Artist", "Album")
+	writeStub(t, filepath.Join(albumPath, "01.mp3"))
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+
+	first := New(root, indexPath, nil)
+	if _, err := first.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.RemoveAll(albumPath); err != nil {
+		t.Fatal(err)
+	}
+
+	second := New(root, indexPath, nil)
+	if err := second.Load(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := second.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Deleted) != 1 || result.Deleted[0] != albumPath {
+		t.Errorf("Deleted = %v, want [%s]", result.Deleted, albumPath)
+	}
+}
+
+func TestScanChangedFileTriggersChanged(t *testing.T) {
+	root := t.TempDir()
+	albumPath := filepath.Join(root, "Artist", "Album")
+	trackPath := filepath.Join(albumPath, "01.mp3")
+	writeStub(t, trackPath)
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+
+	first := New(root, indexPath, nil)
+	if _, err := first.Scan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(trackPath, []byte("stub, but longer now"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	second := New(root, indexPath, nil)
+	if err := second.Load(); err != nil {
+		t.Fatal(err)
+	}
+	result, err := second.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Changed) != 1 || result.Changed[0] != albumPath {
+		t.Errorf("Changed = %v, want [%s]", result.Changed, albumPath)
+	}
+	if len(result.New) != 0 {
+		t.Errorf("New = %v, want empty on a changed rescan", result.New)
+	}
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"log"
+	"math"
 	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/travissimon/songpicker/playlist"
+	"github.com/travissimon/songpicker/scanner"
+	"github.com/travissimon/songpicker/transfer"
 )
 
 type Artist struct {
@@ -29,13 +34,69 @@ type Song struct {
 	Artist   string
 	Album    string
 	Title    string
+	Genre    string
 	Filename string
 	Filesize int
+	Duration time.Duration
+	TrackNum int
+
+	//ReplayGain fields, in dB (gains) or as a linear scale factor (peaks).
+	//Zero when the file carried no ReplayGain tag.
+	TrackGain float64
+	TrackPeak float64
+	AlbumGain float64
+	AlbumPeak float64
 }
 
-type WeightedSong struct {
-	Song   *Song
-	Weight float64
+// normalizeMode selects which ReplayGain value (if any) getDistributedRandom
+// and copySongs should use to smooth out loudness jumps.
+type normalizeMode string
+
+const (
+	normalizeNone  normalizeMode = "none"
+	normalizeTrack normalizeMode = "track"
+	normalizeAlbum normalizeMode = "album"
+)
+
+// gain returns the ReplayGain value that mode selects for song, and whether
+// the song actually carries one.
+func (mode normalizeMode) gain(song *Song) (float64, bool) {
+	if song == nil {
+		return 0, false
+	}
+	switch mode {
+	case normalizeTrack:
+		return song.TrackGain, song.TrackGain != 0
+	case normalizeAlbum:
+		return song.AlbumGain, song.AlbumGain != 0
+	default:
+		return 0, false
+	}
+}
+
+// parseReplayGain parses a raw ReplayGain tag value such as "-3.20 dB" or
+// "0.987654", returning 0 if raw is empty or malformed.
+func parseReplayGain(raw string) float64 {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimSuffix(strings.ToUpper(raw), "DB")
+	raw = strings.TrimSpace(raw)
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// parseTrackNum parses a raw track-number tag value, which may be a bare
+// number ("5") or "track/total" ("5/12"), returning 0 if raw is empty or
+// malformed.
+func parseTrackNum(raw string) int {
+	raw = strings.SplitN(strings.TrimSpace(raw), "/", 2)[0]
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0
+	}
+	return n
 }
 
 var artistLookup = make(map[string]*Artist)
@@ -97,264 +158,371 @@ func listAll() {
 func main() {
 	var srcDir = flag.String("src", "."+string(filepath.Separator), "the directory where we find our mp3s")
 	var destDir = flag.String("dest", "."+string(filepath.Separator), "the directory where we should put the copies")
+	var tagBackends = flag.String("tags", "", "comma-separated tag backends to try, in order (id3v1,id3v2,flac,mp4); empty tries all")
+	var normalize = flag.String("normalize", string(normalizeNone), "smooth loudness jumps using ReplayGain: track, album, or none")
+	var format = flag.String("format", "", "write the playlist to stdout in this format instead of copying: m3u, m3u8, pls, json, cue")
+	var tmplStr = flag.String("template", transfer.DefaultTemplate, "text/template for each copied song's destination path, relative to -dest")
+	var jobs = flag.Int("jobs", 4, "number of concurrent copies when writing to -dest")
+	var splitSize = flag.Int64("split-size", 0, "optional cap, in bytes, on cumulative size per numbered destination subfolder; 0 disables it")
+	var minArtistGap = flag.Int("min-artist-gap", 0, "minimum number of tracks between two songs by the same artist; 0 disables it")
+	var minAlbumGap = flag.Int("min-album-gap", 0, "minimum number of tracks between two songs from the same album; 0 disables it")
+	var maxConsecutiveGenre = flag.Int("max-consecutive-genre", 0, "maximum number of consecutive tracks sharing a genre; 0 disables it")
 
 	flag.Parse()
 
-	loadSongs(srcDir)
-	songs := getDistributedRandom()
+	var tagNames []string
+	if *tagBackends != "" {
+		tagNames = strings.Split(*tagBackends, ",")
+	}
+	mode := normalizeMode(*normalize)
+	constraints := Constraints{
+		MinArtistGap:        *minArtistGap,
+		MinAlbumGap:         *minAlbumGap,
+		MaxConsecutiveGenre: *maxConsecutiveGenre,
+	}
 
-	fmt.Println("Should write to: ", destDir)
+	loadSongs(srcDir, tagNames)
+	songs := getDistributedRandom(mode, constraints)
 
-	for _, song := range songs {
-		fmt.Println(song.Artist, " - ", song.Title)
+	if *format != "" {
+		if err := playlist.Write(os.Stdout, toPlaylistTracks(songs), playlist.Format(*format)); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-}
 
-//getTrailingBytes opens a file and reads the last n bytes
-func getTrailingBytes(filename string, n int) ([]byte, error) {
-	f, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	_, err = f.Seek(-int64(n), os.SEEK_END)
-	if err != nil {
-		return nil, err
+	fmt.Println("Writing to: ", *destDir)
+	if err := copySongs(songs, *destDir, *tmplStr, *jobs, *splitSize, mode); err != nil {
+		log.Fatal(err)
 	}
-	b := make([]byte, n)
-	_, err = f.Read(b)
-	if err != nil {
-		return nil, err
-	}
-	return b, nil
-}
-
-//Tag represents anything that can produce a list of details
-type Tag interface {
-	//Parse returns the complete list of all data found in the tag
-	Parse() map[string]interface{}
-	//String returns the canonical formatted string
-	String() string
 }
 
-//mp3ID3v1 is a specific kind of tagging
-type mp3ID3v1 []byte
-
-//Parse decodes the ID3v1 tag
-//According to wikipedia, track number is in here somewhere too
-//http://en.wikipedia.org/wiki/ID3#Layout
-func (mp3 mp3ID3v1) Parse() map[string]interface{} {
-	m := make(map[string]interface{}, 8)
-	if string(mp3[:3]) != "TAG" {
-		return nil
-	}
-	m["title"] = strings.TrimSpace(string(mp3[3:33]))
-	m["artist"] = strings.TrimSpace(string(mp3[33:63]))
-	m["album"] = strings.TrimSpace(string(mp3[63:93]))
-	m["year"] = strings.TrimSpace(string(mp3[93:97]))
-	m["comment"] = strings.TrimSpace(string(mp3[97:126]))
-	m["genre"] = int(mp3[127])
-	return m
-}
-
-//If a particular Tag had additional fields (personal rating?)
-//we could provide a different function to display them
-func (mp3 mp3ID3v1) String() string {
-	return defaultFormat(mp3.Parse())
+// toPlaylistTracks converts loaded Songs into the playlist package's Track
+// type.
+func toPlaylistTracks(songs []*Song) []playlist.Track {
+	tracks := make([]playlist.Track, len(songs))
+	for i, song := range songs {
+		tracks[i] = playlist.Track{
+			Artist:   song.Artist,
+			Album:    song.Album,
+			Title:    song.Title,
+			Filename: song.Filename,
+			Duration: song.Duration,
+		}
+	}
+	return tracks
 }
 
-func keyEqualsValue(m map[string]interface{}, s string) string {
-	return fmt.Sprintf("%s=%v\n", s, m[s])
-}
+// indexFileName is the name of the persistent scan index kept in srcDir.
+const indexFileName = ".songpicker-index.json"
 
-//defaultFormat should display the tag information like the example
-func defaultFormat(m map[string]interface{}) (s string) {
-	s += keyEqualsValue(m, "album")
-	s += keyEqualsValue(m, "artist")
-	s += keyEqualsValue(m, "title")
-	s += keyEqualsValue(m, "genre")
-	s += keyEqualsValue(m, "year")
-	s += keyEqualsValue(m, "comment")
-	return
-}
+// loadSongs recursively scans srcDir via the scanner package, re-reading
+// tags only for folders that are new or have changed since the last run,
+// and populates artistLookup from the result.
+func loadSongs(srcDir *string, tagNames []string) {
+	sc := scanner.New(*srcDir, path.Join(*srcDir, indexFileName), tagNames)
+	if err := sc.Load(); err != nil {
+		log.Printf("could not load scan index, rescanning from scratch: %v", err)
+	}
 
-func loadSongs(srcDir *string) {
-	files, _ := filepath.Glob(path.Join(*srcDir, "*.mp3"))
+	result, err := sc.Scan(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := sc.Save(); err != nil {
+		log.Printf("could not save scan index: %v", err)
+	}
 
-	for _, f := range files {
-		b, err := getTrailingBytes(f, 128)
-		if err != nil {
-			log.Fatal(err)
+	log.Printf("scan: %d new, %d changed, %d deleted folders", len(result.New), len(result.Changed), len(result.Deleted))
+
+	for _, track := range result.Tracks {
+		song := &Song{
+			Title:     track.Title,
+			Album:     track.Album,
+			Artist:    track.Artist,
+			Genre:     track.Genre,
+			Filename:  track.Filename,
+			Filesize:  int(track.Filesize),
+			Duration:  track.Duration,
+			TrackNum:  parseTrackNum(track.TrackNum),
+			TrackGain: parseReplayGain(track.TrackGain),
+			TrackPeak: parseReplayGain(track.TrackPeak),
+			AlbumGain: parseReplayGain(track.AlbumGain),
+			AlbumPeak: parseReplayGain(track.AlbumPeak),
 		}
-		var tag = mp3ID3v1(b)
-		var fields = tag.Parse()
-
-		song := &Song{}
-		song.Title = fields["title"].(string)
-		song.Album = fields["album"].(string)
-		song.Artist = fields["artist"].(string)
-		song.Filename = f
-
-		fi, _ := os.Stat(f)
-		song.Filesize = int(fi.Size())
 
 		artist := getArtist(song.Artist)
 		artist.addSong(song)
 	}
 }
 
-type ByWeight []*WeightedSong
-
-func (a ByWeight) Len() int           { return len(a) }
-func (a ByWeight) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByWeight) Less(i, j int) bool { return a[i].Weight < a[j].Weight }
-
-func getDistributedRandom() []*Song {
-	var allSongs = make([]*WeightedSong, 0)
-	rand.Seed(time.Now().UnixNano())
-
-	for k := range artistLookup {
-		artist := artistLookup[k]
-		albums := artist.getAlbums()
-
-		weightedSongs := make([]*WeightedSong, 0)
-		for _, album := range albums {
-			songIndicies := rand.Perm(len(album.Songs))
-
-			distribution := float64(1) / float64(len(album.Songs)+1)
-			variability := distribution / float64(2)
-			distribution -= variability
-			variability *= 2
-
-			current := float64(0)
-
-			for idx := range songIndicies {
-				song := album.Songs[idx]
-				weighted := &WeightedSong{}
-				weighted.Song = song
-
-				current += distribution
-				current += rand.Float64() * variability
-				weighted.Weight = current
-				weightedSongs = append(weightedSongs, weighted)
+// gainDampScale is roughly the spread (in dB) of ReplayGain values across a
+// typical library; a song this far from the library average has its
+// sampling weight roughly halved.
+const gainDampScale = 6.0
+
+// libraryAverageGain returns the mean ReplayGain value (as selected by
+// mode) across every song that has one, and whether any song did.
+func libraryAverageGain(mode normalizeMode) (avg float64, ok bool) {
+	var sum float64
+	var count int
+	for _, artist := range artistLookup {
+		for _, album := range artist.getAlbums() {
+			for _, song := range album.Songs {
+				if g, has := mode.gain(song); has {
+					sum += g
+					count++
+				}
 			}
 		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
 
-		sort.Sort(ByWeight(weightedSongs))
+// gainWeight returns an A-Res sampling weight in (0,1] for song, based on
+// how far its gain diverges from the library average: songs with wildly
+// divergent loudness get a lower weight, so they're less likely to land
+// next to an arbitrarily different song in the shuffled output.
+func gainWeight(mode normalizeMode, song *Song, avgGain float64, haveAvg bool) float64 {
+	if !haveAvg {
+		return 1
+	}
+	g, has := mode.gain(song)
+	if !has {
+		return 1
+	}
+	deviation := g - avgGain
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	return 1 / (1 + deviation/gainDampScale)
+}
 
-		distribution := float64(1) / float64(len(weightedSongs)+1)
-		variability := distribution / float64(2)
-		distribution -= variability
-		variability *= 2
+// Constraints are the optional "never again this soon" rules
+// getDistributedRandom's repair pass enforces on its output; zero disables
+// a given constraint.
+type Constraints struct {
+	MinArtistGap        int
+	MinAlbumGap         int
+	MaxConsecutiveGenre int
+}
 
-		current := float64(0)
+func (c Constraints) any() bool {
+	return c.MinArtistGap > 0 || c.MinAlbumGap > 0 || c.MaxConsecutiveGenre > 0
+}
 
-		for _, s := range weightedSongs {
-			current += distribution
-			current += rand.Float64() * variability
-			s.Weight = current
+// maxRepairPasses bounds how many full passes the constraint repair makes
+// before giving up, so a library that can't satisfy every constraint
+// doesn't loop forever.
+const maxRepairPasses = 20
 
-			allSongs = append(allSongs, s)
-		}
+func allSongs() []*Song {
+	var songs []*Song
+	for _, artist := range artistLookup {
+		songs = append(songs, artistSongs(artist)...)
 	}
+	return songs
+}
 
-	sort.Sort(ByWeight(allSongs))
-	songs := make([]*Song, len(allSongs))
-	for i := 0; i < len(allSongs); i++ {
-		songs[i] = allSongs[i].Song
+// artistSongs flattens every song by artist across its albums.
+func artistSongs(artist *Artist) []*Song {
+	var songs []*Song
+	for _, album := range artist.getAlbums() {
+		songs = append(songs, album.Songs...)
 	}
-
 	return songs
 }
 
-func basicRandom(srcDir *string, destDir *string) {
-	files, _ := filepath.Glob(path.Join(*srcDir, "*.mp3"))
+// stratifiedUniform returns a uniform(0,1) value for the i'th of n songs by
+// the same artist, confined to that song's own 1/n-wide slice of the unit
+// interval, instead of the full range. Without this, an A-Res draw's keys
+// come from plain rand.Float64(), so an artist with many songs has no
+// better than random odds of avoiding clusters in the shuffled output. This
+// restores the "spread every artist's songs across the whole output by
+// construction" guarantee the baseline jitter-sort shuffle (and chunk0-3's
+// gain damping on top of it) provided with no flags required; -min-artist-gap
+// and friends remain for a hard per-position guarantee stratification alone
+// can't express.
+func stratifiedUniform(i, n int) float64 {
+	return (float64(i) + rand.Float64()) / float64(n)
+}
 
+// getDistributedRandom shuffles the library with a weighted-reservoir
+// (A-Res) sampler: every song gets a key u^(1/w), for u a per-artist
+// stratifiedUniform draw and w its gainWeight, and the output is those keys
+// sorted in descending order. A bounded swap-based repair pass then nudges
+// the result to satisfy constraints an A-Res draw can't express on its own,
+// such as "no two songs from the same artist within N tracks".
+func getDistributedRandom(mode normalizeMode, constraints Constraints) []*Song {
 	rand.Seed(time.Now().UnixNano())
-	fileOrder := rand.Perm(len(files))
 
-	idx := 1
-	currentFolder := 0
-	var maxFolderSize int64 = 629145600 // 600 MB. My Cd player is crappy :-(
-	currentFolderSize := maxFolderSize + 1
-	newIdx := 1
+	avgGain, haveAvg := libraryAverageGain(mode)
 
-	currentFolderPath := ""
-
-	for _, f := range fileOrder {
-		var buffer bytes.Buffer
-		fName := files[f]
+	type keyedSong struct {
+		song *Song
+		key  float64
+	}
+	var keyed []keyedSong
+	for _, artist := range artistLookup {
+		songs := artistSongs(artist)
+		for i, song := range songs {
+			w := gainWeight(mode, song, avgGain, haveAvg)
+			u := stratifiedUniform(i, len(songs))
+			keyed = append(keyed, keyedSong{song: song, key: math.Pow(u, 1/w)})
+		}
+	}
 
-		title := fName[strings.LastIndex(fName, string(filepath.Separator))+1:]
+	sort.Slice(keyed, func(i, j int) bool { return keyed[i].key > keyed[j].key })
 
-		buffer.WriteString(fmt.Sprintf("%03d", newIdx))
-		buffer.WriteString(" - ")
-		cFound := false
-		spFound := false
-		for _, c := range title {
-			if cFound {
-				buffer.WriteRune(c)
-				continue
-			}
+	result := make([]*Song, len(keyed))
+	for i, k := range keyed {
+		result[i] = k.song
+	}
 
-			// skip leading numbers, dashes and spaces
-			if !spFound && (c >= '0' && c <= '9') {
-				continue
-			}
+	return repairConstraints(result, constraints)
+}
 
-			if !spFound && (c == ' ') {
-				spFound = true
-			}
+// repairConstraints makes up to maxRepairPasses full passes over songs,
+// and whenever a position violates a constraint, swaps in the nearest
+// later song that doesn't. It gives up (leaving whatever violations
+// remain) once a pass makes no swaps, or after maxRepairPasses.
+func repairConstraints(songs []*Song, c Constraints) []*Song {
+	if !c.any() {
+		return songs
+	}
 
-			if !cFound && (c == ' ' || c == '-') {
+	for pass := 0; pass < maxRepairPasses; pass++ {
+		changed := false
+		for i := range songs {
+			if !violatesConstraints(songs, i, c) {
 				continue
 			}
-			cFound = true
-			buffer.WriteRune(c)
+			for j := i + 1; j < len(songs); j++ {
+				songs[i], songs[j] = songs[j], songs[i]
+				if !violatesConstraints(songs, i, c) {
+					changed = true
+					break
+				}
+				songs[i], songs[j] = songs[j], songs[i]
+			}
+		}
+		if !changed {
+			break
 		}
+	}
 
-		newFilename := buffer.String()
-		idx++
-		newIdx++
+	return songs
+}
 
-		fmt.Printf("%s\n", newFilename)
+func violatesConstraints(songs []*Song, i int, c Constraints) bool {
+	if c.MinArtistGap > 0 && repeatsWithinGap(songs, i, c.MinArtistGap, func(s *Song) string { return s.Artist }) {
+		return true
+	}
+	if c.MinAlbumGap > 0 && repeatsWithinGap(songs, i, c.MinAlbumGap, func(s *Song) string { return s.Album }) {
+		return true
+	}
+	if c.MaxConsecutiveGenre > 0 && genreRunExceeds(songs, i, c.MaxConsecutiveGenre) {
+		return true
+	}
+	return false
+}
 
-		if currentFolderSize > maxFolderSize {
-			currentFolder++
-			currentFolderSize = 0
-			currentFolderPath = path.Join(*destDir, fmt.Sprintf("%02d", currentFolder))
-			os.Mkdir(currentFolderPath, 0666)
-			newIdx = 1
+// repeatsWithinGap reports whether any of the gap songs before i share
+// key(songs[i]).
+func repeatsWithinGap(songs []*Song, i, gap int, key func(*Song) string) bool {
+	start := i - gap
+	if start < 0 {
+		start = 0
+	}
+	for j := start; j < i; j++ {
+		if key(songs[j]) == key(songs[i]) {
+			return true
 		}
+	}
+	return false
+}
 
-		fi, _ := os.Stat(fName)
-		currentFolderSize += fi.Size()
-
-		destName := path.Join(currentFolderPath, newFilename)
-		cp(destName, fName)
-		//cpCmd := exec.Command("cp", "", strings.Replace(fName, " ", "\\", -1), strings.Replace(destName, " ", "\\", -1))
-		//err := cpCmd.Run()
-		//if err != nil {
-		//	fmt.Println(err)
-		//}
+// genreRunExceeds reports whether songs[i] extends a run of consecutive
+// same-genre songs beyond max.
+func genreRunExceeds(songs []*Song, i, max int) bool {
+	if songs[i].Genre == "" {
+		return false
+	}
+	run := 1
+	for j := i - 1; j >= 0 && songs[j].Genre == songs[i].Genre; j-- {
+		run++
 	}
+	return run > max
 }
 
-func cp(dst, src string) error {
-	s, err := os.Open(src)
+// copySongs renders each song into destDir via the transfer package
+// (templated path, MD5 dedup, atomic writes, worker pool), then, when mode
+// calls for ReplayGain normalization, writes a playlist.m3u sidecar into
+// destDir carrying #EXTGAIN hints so downstream players don't need to
+// rescan the files.
+func copySongs(songs []*Song, destDir, tmplStr string, jobs int, splitSize int64, mode normalizeMode) error {
+	engine, err := transfer.New(destDir, tmplStr, jobs, splitSize)
 	if err != nil {
 		return err
 	}
-	// no need to check errors on read only file, we already got everything
-	// we need from the filesystem, so nothing can go wrong now.
-	defer s.Close()
-	d, err := os.Create(dst)
-	if err != nil {
-		return err
+
+	results := engine.Copy(toTransferTracks(songs))
+
+	var gainPlaylist *os.File
+	if mode != normalizeNone {
+		gainPlaylist, err = os.Create(path.Join(destDir, "playlist.m3u"))
+		if err != nil {
+			log.Printf("could not create playlist sidecar: %v", err)
+			gainPlaylist = nil
+		} else {
+			defer gainPlaylist.Close()
+			fmt.Fprintln(gainPlaylist, "#EXTM3U")
+		}
 	}
-	if _, err := io.Copy(d, s); err != nil {
-		d.Close()
-		return err
+
+	for _, r := range results {
+		if r.Err != nil {
+			log.Printf("could not copy %s: %v", r.Track.Filename, r.Err)
+			continue
+		}
+		if gainPlaylist == nil {
+			continue
+		}
+		fmt.Fprintf(gainPlaylist, "#EXTINF:-1,%s - %s\n", r.Track.Artist, r.Track.Title)
+		if g, has := mode.gain(songBySource(songs, r.Track.Filename)); has {
+			fmt.Fprintf(gainPlaylist, "#EXTGAIN:%.2f dB\n", g)
+		}
+		fmt.Fprintln(gainPlaylist, r.Dest)
+	}
+	return nil
+}
+
+// toTransferTracks converts loaded Songs into the transfer package's Track
+// type.
+func toTransferTracks(songs []*Song) []transfer.Track {
+	tracks := make([]transfer.Track, len(songs))
+	for i, song := range songs {
+		tracks[i] = transfer.Track{
+			Artist:   song.Artist,
+			Album:    song.Album,
+			Title:    song.Title,
+			Track:    song.TrackNum,
+			Ext:      strings.TrimPrefix(filepath.Ext(song.Filename), "."),
+			Filename: song.Filename,
+		}
+	}
+	return tracks
+}
+
+// songBySource finds the Song that produced source, or nil.
+func songBySource(songs []*Song, source string) *Song {
+	for _, s := range songs {
+		if s.Filename == source {
+			return s
+		}
 	}
-	return d.Close()
+	return nil
 }
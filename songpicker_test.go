@@ -0,0 +1,140 @@
+package main
+
+import "testing"
+
+func songsWithArtists(artists ...string) []*Song {
+	songs := make([]*Song, len(artists))
+	for i, a := range artists {
+		songs[i] = &Song{Artist: a, Album: a, Genre: a}
+	}
+	return songs
+}
+
+func artistsOf(songs []*Song) []string {
+	out := make([]string, len(songs))
+	for i, s := range songs {
+		out[i] = s.Artist
+	}
+	return out
+}
+
+func TestRepairConstraintsNoConstraintsLeavesOrderUnchanged(t *testing.T) {
+	songs := songsWithArtists("A", "A", "B")
+	got := repairConstraints(songs, Constraints{})
+	if want := []string{"A", "A", "B"}; !equalStrings(artistsOf(got), want) {
+		t.Errorf("artists = %v, want %v", artistsOf(got), want)
+	}
+}
+
+func TestRepairConstraintsEnforcesMinArtistGap(t *testing.T) {
+	songs := songsWithArtists("A", "A", "B", "C")
+	got := repairConstraints(songs, Constraints{MinArtistGap: 1})
+
+	for i := range got {
+		if violatesConstraints(got, i, Constraints{MinArtistGap: 1}) {
+			t.Fatalf("position %d still violates MinArtistGap after repair: %v", i, artistsOf(got))
+		}
+	}
+}
+
+func TestRepairConstraintsGivesUpWhenUnsatisfiable(t *testing.T) {
+	// Every song shares the same artist, so no swap can satisfy a gap of 1;
+	// repair must return without looping forever.
+	songs := songsWithArtists("A", "A", "A")
+	got := repairConstraints(songs, Constraints{MinArtistGap: 1})
+	if len(got) != 3 {
+		t.Fatalf("got %d songs, want 3", len(got))
+	}
+}
+
+func TestViolatesConstraintsMinAlbumGap(t *testing.T) {
+	songs := []*Song{
+		{Album: "X"},
+		{Album: "Y"},
+		{Album: "X"},
+	}
+	if !violatesConstraints(songs, 2, Constraints{MinAlbumGap: 2}) {
+		t.Error("expected position 2 to violate MinAlbumGap 2")
+	}
+	if violatesConstraints(songs, 2, Constraints{MinAlbumGap: 1}) {
+		t.Error("did not expect position 2 to violate MinAlbumGap 1")
+	}
+}
+
+func TestGenreRunExceeds(t *testing.T) {
+	songs := []*Song{
+		{Genre: "Rock"},
+		{Genre: "Rock"},
+		{Genre: "Rock"},
+	}
+	if !genreRunExceeds(songs, 2, 2) {
+		t.Error("expected a 3-song run to exceed max 2")
+	}
+	if genreRunExceeds(songs, 1, 2) {
+		t.Error("did not expect a 2-song run to exceed max 2")
+	}
+}
+
+func TestStratifiedUniformStaysWithinItsOwnSlice(t *testing.T) {
+	const n = 10
+	for i := 0; i < n; i++ {
+		lo, hi := float64(i)/n, float64(i+1)/n
+		for trial := 0; trial < 50; trial++ {
+			u := stratifiedUniform(i, n)
+			if u < lo || u >= hi {
+				t.Fatalf("stratifiedUniform(%d, %d) = %v, want in [%v, %v)", i, n, u, lo, hi)
+			}
+		}
+	}
+}
+
+// TestGetDistributedRandomDefaultSpreadsArtists guards against the default
+// shuffle (no -min-artist-gap etc.) degenerating into plain uniform random,
+// which would let one artist's songs cluster together with no flags to
+// avoid it. It's statistical rather than exact since getDistributedRandom
+// reseeds math/rand internally, but stratification makes adjacent
+// same-artist pairs rare enough that a generous threshold is still a
+// reliable regression check.
+func TestGetDistributedRandomDefaultSpreadsArtists(t *testing.T) {
+	defer func(saved map[string]*Artist) { artistLookup = saved }(artistLookup)
+	artistLookup = make(map[string]*Artist)
+
+	const perArtist = 30
+	for _, name := range []string{"Artist A", "Artist B"} {
+		artist := getArtist(name)
+		for i := 0; i < perArtist; i++ {
+			artist.addSong(&Song{Artist: name, Album: name})
+		}
+	}
+
+	songs := getDistributedRandom(normalizeNone, Constraints{})
+	if len(songs) != 2*perArtist {
+		t.Fatalf("got %d songs, want %d", len(songs), 2*perArtist)
+	}
+
+	adjacentSameArtist := 0
+	for i := 1; i < len(songs); i++ {
+		if songs[i].Artist == songs[i-1].Artist {
+			adjacentSameArtist++
+		}
+	}
+
+	// Two equal-sized artists shuffled with no per-song spread would land
+	// adjacent to their own artist roughly half the time (~29 of 59 gaps);
+	// stratification should keep it well below that.
+	if max := len(songs) * 2 / 5; adjacentSameArtist > max {
+		t.Errorf("adjacent same-artist pairs = %d, want <= %d (out of %d gaps) - default shuffle isn't spreading artists", adjacentSameArtist, max, len(songs)-1)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package tagreader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Duration returns the playback length of the audio file at path, dispatching
+// by extension. FLAC and MP4 durations are read from their stream headers;
+// mp3 duration is estimated from its first frame's bitrate, which is exact
+// for CBR files and a reasonable approximation for VBR ones.
+func Duration(path string) (time.Duration, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		return mp3Duration(path)
+	case ".flac":
+		return flacDuration(path)
+	case ".m4a", ".mp4":
+		return mp4Duration(path)
+	default:
+		return 0, fmt.Errorf("tagreader: no duration support for %s", path)
+	}
+}
+
+// mpegBitrates is the Layer III, MPEG Version 1 bitrate table in kbps,
+// indexed by the frame header's 4-bit bitrate index. This covers the
+// overwhelming majority of mp3s in the wild.
+var mpegBitrates = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+
+var mpegSampleRates = [4]int{44100, 48000, 32000, 0}
+
+// mp3Duration reads the MPEG audio frame header that immediately follows
+// any ID3v2 tag, then estimates duration as (fileSize-tagSize)*8/bitrate.
+// It does not scan past padding or junk: if the bytes right after the tag
+// are not a frame sync, it returns an error.
+func mp3Duration(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	offset := int64(0)
+	header := make([]byte, 10)
+	if _, err := f.Read(header); err == nil && string(header[:3]) == "ID3" {
+		offset = 10 + int64(syncSafeSize(header[6:10]))
+	}
+	if _, err := f.Seek(offset, os.SEEK_SET); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 4)
+	if _, err := f.Read(buf); err != nil {
+		return 0, err
+	}
+	if buf[0] != 0xFF || buf[1]&0xE0 != 0xE0 {
+		return 0, fmt.Errorf("tagreader: no MPEG frame sync found in %s", path)
+	}
+
+	bitrateIdx := (buf[2] >> 4) & 0x0F
+	sampleRateIdx := (buf[2] >> 2) & 0x03
+	bitrate := mpegBitrates[bitrateIdx] * 1000
+	sampleRate := mpegSampleRates[sampleRateIdx]
+	if bitrate == 0 || sampleRate == 0 {
+		return 0, fmt.Errorf("tagreader: invalid MPEG frame header in %s", path)
+	}
+
+	audioBytes := fi.Size() - offset
+	seconds := float64(audioBytes) * 8 / float64(bitrate)
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// flacDuration reads the STREAMINFO metadata block, which records the total
+// sample count and sample rate directly.
+func flacDuration(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := f.Read(magic); err != nil {
+		return 0, err
+	}
+	if string(magic) != "fLaC" {
+		return 0, fmt.Errorf("tagreader: not a FLAC file: %s", path)
+	}
+
+	header := make([]byte, 4)
+	if _, err := f.Read(header); err != nil {
+		return 0, err
+	}
+	blockSize := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+	block := make([]byte, blockSize)
+	if _, err := f.Read(block); err != nil {
+		return 0, err
+	}
+	if len(block) < 18 {
+		return 0, fmt.Errorf("tagreader: truncated STREAMINFO in %s", path)
+	}
+
+	packed := binary.BigEndian.Uint64(block[10:18])
+	sampleRate := packed >> 44
+	totalSamples := packed & 0xFFFFFFFFF
+	if sampleRate == 0 {
+		return 0, fmt.Errorf("tagreader: zero sample rate in %s", path)
+	}
+
+	seconds := float64(totalSamples) / float64(sampleRate)
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// mp4Duration reads the movie header ("mvhd") atom nested under "moov",
+// which stores the overall duration directly as duration/timescale.
+func mp4Duration(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	body, err := io.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+
+	moov, ok := findAtom(body, "moov")
+	if !ok {
+		return 0, fmt.Errorf("tagreader: no moov atom in %s", path)
+	}
+	mvhd, ok := findAtom(moov, "mvhd")
+	if !ok {
+		return 0, fmt.Errorf("tagreader: no mvhd atom in %s", path)
+	}
+
+	if len(mvhd) < 1 {
+		return 0, fmt.Errorf("tagreader: truncated mvhd in %s", path)
+	}
+
+	version := mvhd[0]
+	var timescale, duration uint64
+	if version == 1 {
+		if len(mvhd) < 32 {
+			return 0, fmt.Errorf("tagreader: truncated mvhd in %s", path)
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[20:24]))
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	} else {
+		if len(mvhd) < 20 {
+			return 0, fmt.Errorf("tagreader: truncated mvhd in %s", path)
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mvhd[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	}
+	if timescale == 0 {
+		return 0, fmt.Errorf("tagreader: zero timescale in %s", path)
+	}
+
+	seconds := float64(duration) / float64(timescale)
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,116 @@
+package tagreader
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	Register(flacBackend{})
+}
+
+// flacBackend reads the Vorbis comment metadata block from a FLAC file.
+// https://xiph.org/flac/format.html#metadata_block_vorbis_comment
+type flacBackend struct{}
+
+func (flacBackend) Name() string { return "flac" }
+
+func (flacBackend) CanRead(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".flac")
+}
+
+func (flacBackend) ReadTags(path string) (Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Tags{}, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := f.Read(magic); err != nil {
+		return Tags{}, err
+	}
+	if string(magic) != "fLaC" {
+		return Tags{}, nil
+	}
+
+	for {
+		header := make([]byte, 4)
+		if _, err := f.Read(header); err != nil {
+			return Tags{}, err
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		blockSize := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+
+		if blockType != 4 {
+			// Not a VORBIS_COMMENT block, skip over it.
+			if _, err := f.Seek(int64(blockSize), os.SEEK_CUR); err != nil {
+				return Tags{}, err
+			}
+			if last {
+				return Tags{}, nil
+			}
+			continue
+		}
+
+		block := make([]byte, blockSize)
+		if _, err := f.Read(block); err != nil {
+			return Tags{}, err
+		}
+		return parseVorbisComment(block), nil
+	}
+}
+
+// parseVorbisComment decodes a Vorbis comment block body (vendor string plus
+// a list of length-prefixed "KEY=value" entries, all little-endian).
+func parseVorbisComment(b []byte) Tags {
+	var tags Tags
+	pos := 0
+	if pos+4 > len(b) {
+		return tags
+	}
+	vendorLen := int(binary.LittleEndian.Uint32(b[pos : pos+4]))
+	pos += 4 + vendorLen
+	if pos+4 > len(b) {
+		return tags
+	}
+	count := int(binary.LittleEndian.Uint32(b[pos : pos+4]))
+	pos += 4
+
+	for i := 0; i < count && pos+4 <= len(b); i++ {
+		entryLen := int(binary.LittleEndian.Uint32(b[pos : pos+4]))
+		pos += 4
+		if pos+entryLen > len(b) {
+			break
+		}
+		entry := string(b[pos : pos+entryLen])
+		pos += entryLen
+
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToUpper(kv[0]) {
+		case "TITLE":
+			tags.Title = kv[1]
+		case "ARTIST":
+			tags.Artist = kv[1]
+		case "ALBUM":
+			tags.Album = kv[1]
+		case "TRACKNUMBER":
+			tags.Track = kv[1]
+		case "GENRE":
+			tags.Genre = kv[1]
+		case "DATE":
+			tags.Year = kv[1]
+		case "COMMENT":
+			tags.Comment = kv[1]
+		default:
+			setReplayGainField(&tags, kv[0], kv[1])
+		}
+	}
+	return tags
+}
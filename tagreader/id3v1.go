@@ -0,0 +1,62 @@
+package tagreader
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(id3v1Backend{})
+}
+
+// id3v1Backend reads the trailing 128-byte ID3v1 tag present at the end of
+// many older mp3 files.
+// http://en.wikipedia.org/wiki/ID3#Layout
+type id3v1Backend struct{}
+
+func (id3v1Backend) Name() string { return "id3v1" }
+
+func (id3v1Backend) CanRead(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".mp3")
+}
+
+func (id3v1Backend) ReadTags(path string) (Tags, error) {
+	b, err := getTrailingBytes(path, 128)
+	if err != nil {
+		return Tags{}, err
+	}
+	if string(b[:3]) != "TAG" {
+		return Tags{}, nil
+	}
+
+	tags := Tags{
+		Title:   strings.TrimSpace(string(b[3:33])),
+		Artist:  strings.TrimSpace(string(b[33:63])),
+		Album:   strings.TrimSpace(string(b[63:93])),
+		Year:    strings.TrimSpace(string(b[93:97])),
+		Comment: strings.TrimSpace(string(b[97:126])),
+		Genre:   strconv.Itoa(int(b[127])),
+	}
+	return tags, nil
+}
+
+// getTrailingBytes opens a file and reads the last n bytes.
+func getTrailingBytes(filename string, n int) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	_, err = f.Seek(-int64(n), os.SEEK_END)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	_, err = f.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
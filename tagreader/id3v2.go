@@ -0,0 +1,162 @@
+package tagreader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	Register(id3v2Backend{})
+}
+
+// id3v2Backend reads ID3v2.3/2.4 tags from the start of an mp3 file: a
+// 10-byte header followed by a sequence of frames.
+// http://id3.org/id3v2.4.0-structure
+type id3v2Backend struct{}
+
+func (id3v2Backend) Name() string { return "id3v2" }
+
+func (id3v2Backend) CanRead(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".mp3")
+}
+
+var id3v2FrameFields = map[string]string{
+	"TIT2": "Title",
+	"TPE1": "Artist",
+	"TALB": "Album",
+	"TRCK": "Track",
+	"TCON": "Genre",
+	"TYER": "Year",
+	"COMM": "Comment",
+}
+
+func (id3v2Backend) ReadTags(path string) (Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Tags{}, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 10)
+	if _, err := f.Read(header); err != nil {
+		return Tags{}, err
+	}
+	if string(header[:3]) != "ID3" {
+		return Tags{}, nil
+	}
+	major := header[3]
+	tagSize := syncSafeSize(header[6:10])
+
+	body := make([]byte, tagSize)
+	if _, err := f.Read(body); err != nil {
+		return Tags{}, err
+	}
+
+	var tags Tags
+	pos := 0
+	for pos+10 <= len(body) {
+		id := string(body[pos : pos+4])
+		if id == "\x00\x00\x00\x00" {
+			break
+		}
+
+		var frameSize int
+		if major >= 4 {
+			frameSize = syncSafeSize(body[pos+4 : pos+8])
+		} else {
+			frameSize = int(binary.BigEndian.Uint32(body[pos+4 : pos+8]))
+		}
+		pos += 10
+		if pos+frameSize > len(body) {
+			break
+		}
+		frame := body[pos : pos+frameSize]
+		pos += frameSize
+
+		if id == "TXXX" {
+			desc, value := decodeTXXX(frame)
+			setReplayGainField(&tags, desc, value)
+			continue
+		}
+
+		field, ok := id3v2FrameFields[id]
+		if !ok {
+			continue
+		}
+		value := decodeFrameText(frame)
+		setTagField(&tags, field, value)
+	}
+
+	return tags, nil
+}
+
+// syncSafeSize decodes a 4-byte sync-safe integer, where only the bottom 7
+// bits of each byte carry data.
+func syncSafeSize(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// decodeFrameText strips the leading text-encoding byte and any null
+// terminators/padding from a text frame's payload.
+func decodeFrameText(frame []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+	text := frame[1:]
+	text = []byte(strings.ReplaceAll(string(text), "\x00", ""))
+	return strings.TrimSpace(string(text))
+}
+
+// decodeTXXX splits a user-defined text frame (TXXX) into its description
+// and value, e.g. "REPLAYGAIN_TRACK_GAIN" / "-3.20 dB". The leading byte is
+// the text encoding; description and value are null-separated.
+func decodeTXXX(frame []byte) (desc, value string) {
+	if len(frame) == 0 {
+		return "", ""
+	}
+	parts := strings.SplitN(string(frame[1:]), "\x00", 2)
+	desc = strings.TrimSpace(parts[0])
+	if len(parts) == 2 {
+		value = strings.TrimSpace(strings.ReplaceAll(parts[1], "\x00", ""))
+	}
+	return desc, value
+}
+
+// setReplayGainField stores value in tags' matching ReplayGain field, if
+// desc (a TXXX/Vorbis-comment description) names one.
+func setReplayGainField(tags *Tags, desc, value string) {
+	switch strings.ToUpper(desc) {
+	case "REPLAYGAIN_TRACK_GAIN":
+		tags.TrackGain = value
+	case "REPLAYGAIN_TRACK_PEAK":
+		tags.TrackPeak = value
+	case "REPLAYGAIN_ALBUM_GAIN":
+		tags.AlbumGain = value
+	case "REPLAYGAIN_ALBUM_PEAK":
+		tags.AlbumPeak = value
+	}
+}
+
+func setTagField(t *Tags, field, value string) {
+	switch field {
+	case "Title":
+		t.Title = value
+	case "Artist":
+		t.Artist = value
+	case "Album":
+		t.Album = value
+	case "Track":
+		t.Track = value
+	case "Genre":
+		t.Genre = value
+	case "Year":
+		t.Year = value
+	case "Comment":
+		t.Comment = value
+	default:
+		panic(fmt.Sprintf("tagreader: unknown frame field %q", field))
+	}
+}
@@ -0,0 +1,133 @@
+package tagreader
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register(mp4Backend{})
+}
+
+// mp4Backend reads the iTunes-style metadata atoms ("moov/udta/meta/ilst")
+// from an MP4/M4A container.
+type mp4Backend struct{}
+
+func (mp4Backend) Name() string { return "mp4" }
+
+func (mp4Backend) CanRead(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".m4a" || ext == ".mp4"
+}
+
+var mp4AtomFields = map[string]string{
+	"\xa9nam": "Title",
+	"\xa9ART": "Artist",
+	"\xa9alb": "Album",
+	"trkn":    "Track",
+	"\xa9gen": "Genre",
+	"\xa9day": "Year",
+	"\xa9cmt": "Comment",
+}
+
+func (mp4Backend) ReadTags(path string) (Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Tags{}, err
+	}
+	defer f.Close()
+
+	ilst, err := findAtomPath(f, []string{"moov", "udta", "meta", "ilst"})
+	if err != nil {
+		return Tags{}, err
+	}
+	if ilst == nil {
+		return Tags{}, nil
+	}
+
+	var tags Tags
+	walkAtoms(ilst, func(name string, body []byte) {
+		field, ok := mp4AtomFields[name]
+		if !ok {
+			return
+		}
+		// Each ilst child wraps a "data" atom: 8-byte header, 4-byte type
+		// flags, 4-byte locale, then the raw value.
+		data, ok := findAtom(body, "data")
+		if !ok || len(data) < 8 {
+			return
+		}
+		raw := data[8:]
+		var value string
+		if name == "trkn" {
+			value = parseTrknValue(raw)
+		} else {
+			value = strings.TrimSpace(string(raw))
+		}
+		setTagField(&tags, field, value)
+	})
+	return tags, nil
+}
+
+// parseTrknValue decodes the binary payload of a "trkn" data atom: 2 reserved
+// bytes, a big-endian uint16 track number, then total-tracks/reserved bytes
+// we don't need. It is not text like the other ilst atoms.
+func parseTrknValue(raw []byte) string {
+	if len(raw) < 4 {
+		return ""
+	}
+	track := binary.BigEndian.Uint16(raw[2:4])
+	return strconv.Itoa(int(track))
+}
+
+// findAtomPath descends into a chain of nested atoms (meta has a 4-byte
+// version/flags prefix before its children, like ilst's parent) and returns
+// the raw body of the last one.
+func findAtomPath(r io.Reader, path []string) ([]byte, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range path {
+		found, ok := findAtom(body, name)
+		if !ok {
+			return nil, nil
+		}
+		if name == "meta" && i < len(path)-1 {
+			found = found[4:] // skip meta's version/flags
+		}
+		body = found
+	}
+	return body, nil
+}
+
+// findAtom scans a flat run of atoms for the first one named name and
+// returns its body (excluding the 8-byte size+name header).
+func findAtom(b []byte, name string) ([]byte, bool) {
+	found := false
+	var result []byte
+	walkAtoms(b, func(n string, body []byte) {
+		if !found && n == name {
+			found = true
+			result = body
+		}
+	})
+	return result, found
+}
+
+func walkAtoms(b []byte, fn func(name string, body []byte)) {
+	pos := 0
+	for pos+8 <= len(b) {
+		size := int(binary.BigEndian.Uint32(b[pos : pos+4]))
+		name := string(b[pos+4 : pos+8])
+		if size < 8 || pos+size > len(b) {
+			return
+		}
+		fn(name, b[pos+8:pos+size])
+		pos += size
+	}
+}
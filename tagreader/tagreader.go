@@ -0,0 +1,125 @@
+// Package tagreader provides pluggable backends for extracting tag metadata
+// (artist, album, title, ...) from audio files of various formats.
+package tagreader
+
+import "fmt"
+
+// Tags holds the metadata we care about for a single audio file, regardless
+// of which backend produced it.
+type Tags struct {
+	Title   string
+	Artist  string
+	Album   string
+	Year    string
+	Genre   string
+	Track   string
+	Comment string
+
+	//ReplayGain fields, raw as stored in the tag (e.g. "-3.20 dB" for gains,
+	//"0.987654" for peaks). Empty when the file carries no ReplayGain info.
+	TrackGain string
+	TrackPeak string
+	AlbumGain string
+	AlbumPeak string
+}
+
+// Backend reads tags from a particular file format. Implementations are
+// registered with Register and selected by CanRead.
+type Backend interface {
+	//Name is the short identifier used with the -tags flag, e.g. "id3v1".
+	Name() string
+	//CanRead reports whether this backend is able to handle the given file,
+	//typically based on extension and/or a magic-number sniff.
+	CanRead(path string) bool
+	//ReadTags extracts the tags from path.
+	ReadTags(path string) (Tags, error)
+}
+
+var (
+	registry = make(map[string]Backend)
+	// order records registration order so selection is deterministic: the
+	// first backend to Register for a given file wins ties over later ones.
+	order []string
+)
+
+// Register makes a backend available for selection by name. It is expected
+// to be called from the init() of the package implementing the backend.
+func Register(b Backend) {
+	name := b.Name()
+	if _, exists := registry[name]; !exists {
+		order = append(order, name)
+	}
+	registry[name] = b
+}
+
+// Lookup returns the registered backend with the given name, if any.
+func Lookup(name string) (Backend, bool) {
+	b, ok := registry[name]
+	return b, ok
+}
+
+// Backends returns every backend registered so far, in registration order.
+func Backends() []Backend {
+	all := make([]Backend, 0, len(order))
+	for _, name := range order {
+		all = append(all, registry[name])
+	}
+	return all
+}
+
+// ErrNoTags indicates that no registered backend could find any tags in path.
+type ErrNoTags struct {
+	Path string
+}
+
+func (e ErrNoTags) Error() string {
+	return fmt.Sprintf("tagreader: no tags found in %s", e.Path)
+}
+
+// Read dispatches to backends suitable for path's extension, in order, falling
+// back to the next backend whenever one returns no tags. names restricts the
+// set of backends considered; a nil or empty names reads with every backend
+// that claims it CanRead the file.
+func Read(path string, names []string) (Tags, error) {
+	candidates := selectBackends(path, names)
+	if len(candidates) == 0 {
+		return Tags{}, ErrNoTags{Path: path}
+	}
+
+	var lastErr error
+	for _, b := range candidates {
+		tags, err := b.ReadTags(path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if tags.Title == "" && tags.Artist == "" && tags.Album == "" {
+			continue
+		}
+		return tags, nil
+	}
+
+	if lastErr != nil {
+		return Tags{}, lastErr
+	}
+	return Tags{}, ErrNoTags{Path: path}
+}
+
+func selectBackends(path string, names []string) []Backend {
+	var candidates []Backend
+	if len(names) == 0 {
+		for _, name := range order {
+			b := registry[name]
+			if b.CanRead(path) {
+				candidates = append(candidates, b)
+			}
+		}
+		return candidates
+	}
+	for _, name := range names {
+		if b, ok := registry[name]; ok && b.CanRead(path) {
+			candidates = append(candidates, b)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,158 @@
+package tagreader
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSelectBackendsDefaultOrderIsRegistrationOrder(t *testing.T) {
+	// mp3 is claimed by both id3v1 and id3v2; selection must not depend on
+	// map iteration order.
+	want := []string{}
+	for _, name := range order {
+		b := registry[name]
+		if b.CanRead("song.mp3") {
+			want = append(want, name)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		got := selectBackends("song.mp3", nil)
+		if len(got) != len(want) {
+			t.Fatalf("run %d: got %d candidates, want %d", i, len(got), len(want))
+		}
+		for j, b := range got {
+			if b.Name() != want[j] {
+				t.Fatalf("run %d: candidate %d = %q, want %q", i, j, b.Name(), want[j])
+			}
+		}
+	}
+}
+
+func TestSyncSafeSize(t *testing.T) {
+	cases := []struct {
+		b    [4]byte
+		want int
+	}{
+		{[4]byte{0x00, 0x00, 0x00, 0x00}, 0},
+		{[4]byte{0x00, 0x00, 0x00, 0x7f}, 127},
+		{[4]byte{0x00, 0x00, 0x01, 0x00}, 128},
+	}
+	for _, c := range cases {
+		if got := syncSafeSize(c.b[:]); got != c.want {
+			t.Errorf("syncSafeSize(%v) = %d, want %d", c.b, got, c.want)
+		}
+	}
+}
+
+func TestDecodeFrameText(t *testing.T) {
+	// encoding byte (0 = ISO-8859-1) followed by the text, with a null
+	// terminator as real tag writers often leave behind.
+	frame := append([]byte{0x00}, []byte("Some Title\x00")...)
+	if got, want := decodeFrameText(frame), "Some Title"; got != want {
+		t.Errorf("decodeFrameText = %q, want %q", got, want)
+	}
+}
+
+func TestDecodeTXXX(t *testing.T) {
+	frame := append([]byte{0x00}, []byte("REPLAYGAIN_TRACK_GAIN\x00-3.20 dB")...)
+	desc, value := decodeTXXX(frame)
+	if desc != "REPLAYGAIN_TRACK_GAIN" || value != "-3.20 dB" {
+		t.Errorf("decodeTXXX = (%q, %q), want (%q, %q)", desc, value, "REPLAYGAIN_TRACK_GAIN", "-3.20 dB")
+	}
+}
+
+func TestParseVorbisComment(t *testing.T) {
+	entries := []string{
+		"TITLE=Test Song",
+		"ARTIST=Test Artist",
+		"TRACKNUMBER=7",
+		"REPLAYGAIN_TRACK_GAIN=-3.20 dB",
+	}
+
+	var body []byte
+	vendor := "test vendor"
+	body = append(body, le32(len(vendor))...)
+	body = append(body, vendor...)
+	body = append(body, le32(len(entries))...)
+	for _, e := range entries {
+		body = append(body, le32(len(e))...)
+		body = append(body, e...)
+	}
+
+	tags := parseVorbisComment(body)
+	if tags.Title != "Test Song" {
+		t.Errorf("Title = %q, want %q", tags.Title, "Test Song")
+	}
+	if tags.Artist != "Test Artist" {
+		t.Errorf("Artist = %q, want %q", tags.Artist, "Test Artist")
+	}
+	if tags.Track != "7" {
+		t.Errorf("Track = %q, want %q", tags.Track, "7")
+	}
+	if tags.TrackGain != "-3.20 dB" {
+		t.Errorf("TrackGain = %q, want %q", tags.TrackGain, "-3.20 dB")
+	}
+}
+
+func TestParseTrknValue(t *testing.T) {
+	// reserved(2) + track(2, big-endian) + total(2) + reserved(2)
+	raw := []byte{0x00, 0x00, 0x00, 0x05, 0x00, 0x0a, 0x00, 0x00}
+	if got, want := parseTrknValue(raw), "5"; got != want {
+		t.Errorf("parseTrknValue = %q, want %q", got, want)
+	}
+}
+
+func le32(n int) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(n))
+	return b
+}
+
+// atom builds a single BigEndian-size-prefixed MP4 atom.
+func atom(name string, body []byte) []byte {
+	b := make([]byte, 8+len(body))
+	binary.BigEndian.PutUint32(b[0:4], uint32(len(b)))
+	copy(b[4:8], name)
+	copy(b[8:], body)
+	return b
+}
+
+func writeMP4(t *testing.T, mvhdBody []byte) string {
+	t.Helper()
+	mvhd := atom("mvhd", mvhdBody)
+	moov := atom("moov", mvhd)
+	path := filepath.Join(t.TempDir(), "test.m4a")
+	if err := os.WriteFile(path, moov, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMP4DurationTruncatedMvhdErrors(t *testing.T) {
+	// A version-0 mvhd body truncated well short of the 20 bytes needed to
+	// reach its timescale/duration fields.
+	path := writeMP4(t, []byte{0x00, 0x00, 0x00, 0x00})
+	if _, err := mp4Duration(path); err == nil {
+		t.Fatal("expected an error for a truncated mvhd, got nil")
+	}
+}
+
+func TestMP4DurationVersion0(t *testing.T) {
+	body := make([]byte, 20)
+	// body[0] = version 0
+	binary.BigEndian.PutUint32(body[12:16], 1000) // timescale
+	binary.BigEndian.PutUint32(body[16:20], 5000) // duration, in timescale units
+	path := writeMP4(t, body)
+
+	got, err := mp4Duration(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := 5 * time.Second; got != want {
+		t.Errorf("duration = %v, want %v", got, want)
+	}
+}
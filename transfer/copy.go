@@ -0,0 +1,121 @@
+package transfer
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// tmpSeq disambiguates concurrent in-flight copies that render to the same
+// destination path (e.g. untagged files that all collapse to the same
+// fallback name): without a unique suffix, two workers' dest+".tmp" would
+// collide and race each other's os.Rename.
+var tmpSeq int64
+
+// destLocks serializes copyOne calls that target the same dest path, so two
+// workers racing to fill in the same fallback name see each other's result
+// (via the Stat below) instead of both independently copying and one
+// silently clobbering the other.
+var destLocks sync.Map // map[string]*sync.Mutex
+
+func lockDest(dest string) func() {
+	l, _ := destLocks.LoadOrStore(dest, &sync.Mutex{})
+	mu := l.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// copyOne copies src to dest, skipping the copy if an identical file (by
+// MD5) already sits at dest, logging (but not overwriting) if a
+// differently-hashed file already occupies dest's name, and otherwise
+// writing atomically: copy to a uniquely numbered dest+".N.tmp", then
+// rename into place.
+func copyOne(src, dest string) error {
+	unlock := lockDest(dest)
+	defer unlock()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("transfer: creating %s: %w", filepath.Dir(dest), err)
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		same, err := sameContents(src, dest)
+		if err != nil {
+			return err
+		}
+		if same {
+			return nil
+		}
+		log.Printf("transfer: %s already exists with different contents, skipping %s", dest, src)
+		return nil
+	}
+
+	tmp := fmt.Sprintf("%s.%d.tmp", dest, atomic.AddInt64(&tmpSeq, 1))
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("transfer: renaming %s into place: %w", dest, err)
+	}
+	return nil
+}
+
+func copyFile(src, dest string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	d, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(d, s); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// sameContents reports whether a and b have identical MD5 hashes.
+func sameContents(a, b string) (bool, error) {
+	hashA, err := hashFile(a)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := hashFile(b)
+	if err != nil {
+		return false, err
+	}
+	return hashA == hashB, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func fileSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
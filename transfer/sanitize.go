@@ -0,0 +1,20 @@
+package transfer
+
+import "strings"
+
+// hostileChars are characters that are illegal, or awkward, in a filename
+// on at least one common filesystem.
+const hostileChars = `<>:"/\|?*`
+
+// sanitize replaces filesystem-hostile characters in a single path
+// component with "_" and trims trailing dots/spaces, which Windows
+// rejects.
+func sanitize(component string) string {
+	replaced := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(hostileChars, r) {
+			return '_'
+		}
+		return r
+	}, component)
+	return strings.TrimRight(replaced, " .")
+}
@@ -0,0 +1,161 @@
+// Package transfer renames and copies songs into a destination library laid
+// out by a user-supplied template, with duplicate detection, an atomic
+// write pattern, and a bounded worker pool for parallel copies.
+package transfer
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Track is the subset of song metadata the destination path template can
+// reference.
+type Track struct {
+	Artist   string
+	Album    string
+	Title    string
+	Track    int
+	Ext      string
+	Filename string // source path
+}
+
+// DefaultTemplate lays songs out as Artist/Album/NN - Title.ext.
+const DefaultTemplate = `{{.Artist}}/{{.Album}}/{{printf "%02d" .Track}} - {{.Title}}.{{.Ext}}`
+
+// Engine copies Tracks into DestDir, rendering each one's path from a
+// template and running up to Jobs copies concurrently.
+type Engine struct {
+	DestDir   string
+	Jobs      int
+	SplitSize int64 // optional cap, in bytes, on cumulative size per numbered subfolder; 0 disables it
+
+	tmpl *template.Template
+}
+
+// New builds an Engine that renders destination paths from templateStr
+// (text/template syntax over a Track). jobs is clamped to at least 1.
+func New(destDir, templateStr string, jobs int, splitSize int64) (*Engine, error) {
+	tmpl, err := template.New("transfer").Parse(templateStr)
+	if err != nil {
+		return nil, fmt.Errorf("transfer: invalid template: %w", err)
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	return &Engine{DestDir: destDir, Jobs: jobs, SplitSize: splitSize, tmpl: tmpl}, nil
+}
+
+// plan is one fully-resolved source/destination pair, ready to copy.
+type plan struct {
+	track Track
+	dest  string
+}
+
+// Result reports the outcome of copying a single track, including its
+// resolved destination path so callers can build sidecar files (e.g.
+// playlists) that reference the final layout.
+type Result struct {
+	Track Track
+	Dest  string
+	Err   error
+}
+
+// Copy renders and copies every track, returning one Result per track (a
+// failure on one doesn't stop the rest). Results for tracks whose path fails
+// to render come first; the rest follow in submission order.
+func (e *Engine) Copy(tracks []Track) []Result {
+	plans, badResults := e.buildPlans(tracks)
+	return append(badResults, e.runPlans(plans)...)
+}
+
+// renderPath executes the template for t and sanitizes each path component
+// (directories included) of filesystem-hostile characters.
+func (e *Engine) renderPath(t Track) (string, error) {
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, t); err != nil {
+		return "", fmt.Errorf("transfer: rendering path for %s: %w", t.Filename, err)
+	}
+
+	parts := strings.Split(filepath.ToSlash(buf.String()), "/")
+	for i, p := range parts {
+		parts[i] = sanitize(p)
+	}
+	return filepath.Join(parts...), nil
+}
+
+// buildPlans sequentially renders every track's destination path (applying
+// the optional split-size policy, which needs a running total and so can't
+// be parallelized) ahead of the concurrent copy phase. Tracks whose path
+// fails to render are returned directly as failed Results.
+func (e *Engine) buildPlans(tracks []Track) ([]plan, []Result) {
+	var failed []Result
+	plans := make([]plan, 0, len(tracks))
+
+	folderIdx := 0
+	var folderSize int64
+
+	for _, t := range tracks {
+		relPath, err := e.renderPath(t)
+		if err != nil {
+			failed = append(failed, Result{Track: t, Err: err})
+			continue
+		}
+
+		if e.SplitSize > 0 {
+			size := fileSize(t.Filename)
+			if folderSize > 0 && folderSize+size > e.SplitSize {
+				folderIdx++
+				folderSize = 0
+			}
+			folderSize += size
+			relPath = filepath.Join(fmt.Sprintf("%02d", folderIdx), relPath)
+		}
+
+		plans = append(plans, plan{track: t, dest: filepath.Join(e.DestDir, relPath)})
+	}
+
+	return plans, failed
+}
+
+// indexedPlan tags a plan with its position in the original tracks slice, so
+// runPlans can restore submission order once workers finish out of order.
+type indexedPlan struct {
+	plan
+	idx int
+}
+
+// runPlans executes plans with up to e.Jobs copies running concurrently.
+// Results are written into a pre-sized slice by original index, so the
+// returned order always matches plans regardless of completion order -
+// callers (e.g. playlist generation) depend on that to preserve the
+// caller's shuffle order.
+func (e *Engine) runPlans(plans []plan) []Result {
+	jobs := make(chan indexedPlan)
+	results := make([]Result, len(plans))
+	var wg sync.WaitGroup
+
+	for i := 0; i < e.Jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				err := copyOne(p.track.Filename, p.dest)
+				results[p.idx] = Result{Track: p.track, Dest: p.dest, Err: err}
+			}
+		}()
+	}
+
+	go func() {
+		for i, p := range plans {
+			jobs <- indexedPlan{plan: p, idx: i}
+		}
+		close(jobs)
+	}()
+
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,89 @@
+package transfer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSrc(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCopyPreservesSubmissionOrder(t *testing.T) {
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+
+	const n = 20
+	tracks := make([]Track, n)
+	for i := 0; i < n; i++ {
+		title := fmt.Sprintf("T%02d", i)
+		src := writeSrc(t, srcDir, title+".src", "content")
+		tracks[i] = Track{
+			Artist:   "artist",
+			Album:    "album",
+			Title:    title,
+			Track:    i,
+			Ext:      "mp3",
+			Filename: src,
+		}
+	}
+
+	e, err := New(destDir, `{{.Title}}.{{.Ext}}`, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := e.Copy(tracks)
+
+	if len(results) != n {
+		t.Fatalf("got %d results, want %d", len(results), n)
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			t.Fatalf("result %d: unexpected error: %v", i, r.Err)
+		}
+		want := fmt.Sprintf("T%02d", i)
+		if r.Track.Title != want {
+			t.Errorf("result %d: Title = %q, want %q (results out of submission order)", i, r.Track.Title, want)
+		}
+	}
+}
+
+func TestCopyOneConcurrentSameDestDoesNotRace(t *testing.T) {
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+
+	srcA := writeSrc(t, srcDir, "a.mp3", "content A")
+	srcB := writeSrc(t, srcDir, "b.mp3", "content B")
+	dest := filepath.Join(destDir, "00 - .mp3")
+
+	done := make(chan error, 2)
+	go func() { done <- copyOne(srcA, dest) }()
+	go func() { done <- copyOne(srcB, dest) }()
+
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("copyOne returned error instead of falling back to the dedup/log path: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(dest); err != nil {
+		t.Fatalf("dest missing after concurrent copy: %v", err)
+	}
+	entries, err := os.ReadDir(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("leftover tmp file: %s", e.Name())
+		}
+	}
+}